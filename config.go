@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the parsed contents of an alrmrc monitoring config file.
+type Config struct {
+	Interval time.Duration
+	Groups   []*Group
+}
+
+// Group is a named collection of hosts that share a monitoring interval.
+type Group struct {
+	Name  string
+	Hosts []*Host
+}
+
+// Host is a single monitored endpoint and the checks to run against it.
+type Host struct {
+	Name    string
+	Address string
+	Checks  []Check
+}
+
+// Check is implemented by each kind of monitor (ping, and eventually tcp,
+// http, etc). Parse consumes the remainder of a "check <type> ..." config
+// line and reports whether it was well-formed; Check performs the check.
+type Check interface {
+	Parse(args string) (bool, error)
+	Check() error
+}
+
+// newCheck returns a new, unconfigured Check for the given check type name,
+// or nil if the type is not recognized. This is the plug-point for adding
+// new check types (tcp, http, ...) alongside CheckPing.
+func newCheck(name string, host *Host) Check {
+	switch name {
+	case "ping":
+		return &CheckPing{Address: host.Address}
+	default:
+		return nil
+	}
+}
+
+// stripComment removes everything from the first '#' onward, since comments
+// are allowed anywhere on a line.
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	return line
+}
+
+// ParseConfig reads an alrmrc-formatted monitoring config from r. The
+// grammar is line-oriented: `set interval <seconds>`, `monitor group <name>`,
+// `host <name> address <addr>`, and an indented `check <type> [args]` under
+// each host.
+func ParseConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{Interval: 30 * time.Second}
+
+	var group *Group
+	var host *Host
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		switch fields[0] {
+		case "set":
+			if len(fields) != 3 || fields[1] != "interval" {
+				return nil, fmt.Errorf("line %d: malformed set directive: %q", lineNo, line)
+			}
+
+			seconds, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid interval: %w", lineNo, err)
+			}
+
+			cfg.Interval = time.Duration(seconds) * time.Second
+		case "monitor":
+			if len(fields) != 3 || fields[1] != "group" {
+				return nil, fmt.Errorf("line %d: malformed monitor directive: %q", lineNo, line)
+			}
+
+			group = &Group{Name: fields[2]}
+			host = nil
+			cfg.Groups = append(cfg.Groups, group)
+		case "host":
+			if group == nil {
+				return nil, fmt.Errorf("line %d: host defined outside of a monitor group", lineNo)
+			}
+			if len(fields) != 4 || fields[2] != "address" {
+				return nil, fmt.Errorf("line %d: malformed host directive: %q", lineNo, line)
+			}
+
+			host = &Host{Name: fields[1], Address: fields[3]}
+			group.Hosts = append(group.Hosts, host)
+		case "check":
+			if host == nil {
+				return nil, fmt.Errorf("line %d: check defined outside of a host", lineNo)
+			}
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: malformed check directive: %q", lineNo, line)
+			}
+
+			check := newCheck(fields[1], host)
+			if check == nil {
+				return nil, fmt.Errorf("line %d: unknown check type: %q", lineNo, fields[1])
+			}
+
+			args := ""
+			if len(fields) > 2 {
+				args = strings.Join(fields[2:], " ")
+			}
+
+			ok, err := check.Parse(args)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid check config: %q", lineNo, line)
+			}
+
+			host.Checks = append(host.Checks, check)
+		default:
+			return nil, fmt.Errorf("line %d: unrecognized line: %q", lineNo, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
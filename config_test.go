@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigHappyPath(t *testing.T) {
+	const src = `
+# a comment on its own line
+set interval 45
+
+monitor group web
+	host front address example.com # trailing comment
+		check ping
+`
+
+	cfg, err := ParseConfig(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+
+	if cfg.Interval != 45*time.Second {
+		t.Errorf("Interval = %v, want 45s", cfg.Interval)
+	}
+
+	if len(cfg.Groups) != 1 {
+		t.Fatalf("len(Groups) = %d, want 1", len(cfg.Groups))
+	}
+
+	group := cfg.Groups[0]
+	if group.Name != "web" {
+		t.Errorf("group.Name = %q, want %q", group.Name, "web")
+	}
+
+	if len(group.Hosts) != 1 {
+		t.Fatalf("len(Hosts) = %d, want 1", len(group.Hosts))
+	}
+
+	host := group.Hosts[0]
+	if host.Name != "front" || host.Address != "example.com" {
+		t.Errorf("host = %+v, want Name=front Address=example.com", host)
+	}
+
+	if len(host.Checks) != 1 {
+		t.Fatalf("len(Checks) = %d, want 1", len(host.Checks))
+	}
+
+	if _, ok := host.Checks[0].(*CheckPing); !ok {
+		t.Errorf("Checks[0] = %T, want *CheckPing", host.Checks[0])
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"host outside group", "host front address example.com"},
+		{"check outside host", "monitor group web\ncheck ping"},
+		{"unknown check type", "monitor group web\nhost front address example.com\ncheck tcp"},
+		{"malformed set interval", "set interval soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseConfig(strings.NewReader(tt.src)); err == nil {
+				t.Errorf("ParseConfig(%q) succeeded, want an error", tt.src)
+			}
+		})
+	}
+}
@@ -4,24 +4,89 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
-// listener opens a new PacketConn, listening for ipv4 icmp requests. When
-// received, it parses the request and sends it to the results channel.
-func Listener(conn chan *icmp.PacketConn, results chan Result,
-	quit chan struct{}) {
-	c, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-	if err != nil {
-		log.Fatal(err)
+// Conns holds the packet connections used to send and receive icmp traffic.
+// IPv4 and IPv6 each require their own socket, so a separate conn is kept for
+// each address family.
+type Conns struct {
+	v4 *icmp.PacketConn
+	v6 *icmp.PacketConn
+}
+
+// Listener opens a PacketConn for both ipv4 and ipv6 icmp requests, and
+// listens on each in its own goroutine. When a reply is received, it is
+// parsed and sent to the results channel. If useUDP is set, unprivileged
+// udp4/udp6 datagram sockets are used instead of raw icmp sockets, which
+// does not require root or CAP_NET_RAW.
+//
+// A host with one address family disabled (e.g. IPv6 turned off) is common,
+// so failing to open one family's socket only drops that family rather than
+// aborting the whole program; only failing both is fatal.
+func Listener(conns chan *Conns, results chan Result, errors chan Error,
+	quit chan struct{}, useUDP bool) {
+	network4, network6 := "ip4:icmp", "ip6:ipv6-icmp"
+	if useUDP {
+		network4, network6 = "udp4", "udp6"
+	}
+
+	c4, err4 := icmp.ListenPacket(network4, "0.0.0.0")
+	if err4 != nil {
+		fmt.Fprintf(os.Stderr, "ipv4 icmp listener unavailable: %v\n", err4)
+	} else {
+		defer c4.Close()
 	}
-	defer c.Close()
-	conn <- c
+
+	c6, err6 := icmp.ListenPacket(network6, "::")
+	if err6 != nil {
+		fmt.Fprintf(os.Stderr, "ipv6 icmp listener unavailable: %v\n", err6)
+	} else {
+		defer c6.Close()
+	}
+
+	if c4 == nil && c6 == nil {
+		log.Fatalf("unable to open an icmp listener for either address family: v4: %v; v6: %v", err4, err6)
+	}
+
+	conns <- &Conns{c4, c6}
+
+	var wg sync.WaitGroup
+	if c4 != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listen(c4, ipv4.ICMPTypeEchoReply, results, quit)
+		}()
+	}
+	if c6 != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listen(c6, ipv6.ICMPTypeEchoReply, results, quit)
+		}()
+	}
+	wg.Wait()
+}
+
+// listen reads packets from c in an infinite loop, parsing echo replies that
+// match wantType and sending the results to the results channel.
+func listen(c *icmp.PacketConn, wantType icmp.Type, results chan Result,
+	quit chan struct{}) {
 	reply := make([]byte, 1500)
 
+	// The protocol number passed to icmp.ParseMessage differs between ipv4
+	// and ipv6, so derive it from which reply type this loop is watching for.
+	protocol := 1
+	if wantType == ipv6.ICMPTypeEchoReply {
+		protocol = 58
+	}
+
 	// Listen for packets in an infinite loop.
 	for {
 		n, peer, err := c.ReadFrom(reply)
@@ -31,17 +96,23 @@ func Listener(conn chan *icmp.PacketConn, results chan Result,
 		}
 		endTime := time.Now()
 
-		rm, err := icmp.ParseMessage(1, reply[:n])
+		rm, err := icmp.ParseMessage(protocol, reply[:n])
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			return
 		}
 		switch rm.Type {
-		case ipv4.ICMPTypeEchoReply:
+		case wantType:
 			switch pkt := rm.Body.(type) {
 			case *icmp.Echo:
+				// Copy the payload out of reply before it's overwritten by the next
+				// ReadFrom, so the caller can verify the embedded token and
+				// timestamp.
+				payload := make([]byte, len(pkt.Data))
+				copy(payload, pkt.Data)
+
 				// If it is a valid echo packet, send the result to the results channel.
-				results <- Result{pkt.ID, pkt.Seq, endTime}
+				results <- Result{endTime, payload}
 			default:
 			}
 		default:
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// RunConfigFile loads an alrmrc monitoring config from path and runs its
+// groups until the user presses q.
+func RunConfigFile(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Unable to open config file: %v.\n", err)
+	}
+	defer f.Close()
+
+	cfg, err := ParseConfig(f)
+	if err != nil {
+		log.Fatalf("Unable to parse config file: %v.\n", err)
+	}
+
+	if len(cfg.Groups) == 0 {
+		log.Fatalln("Config file defines no monitor groups.")
+	}
+
+	quit := make(chan struct{})
+
+	// Read keyboard input for q.
+	go func() {
+		// Disable input buffering
+		exec.Command("stty", "-F", "/dev/tty", "cbreak", "min", "1").Run()
+		// Do not display entered characters on the screen
+		exec.Command("stty", "-F", "/dev/tty", "-echo").Run()
+		var b []byte = make([]byte, 1)
+
+		for {
+			os.Stdin.Read(b)
+			if b[0] == 'q' {
+				close(quit)
+				return
+			}
+		}
+	}()
+
+	fmt.Println("Press q to quit.")
+
+	Monitor(cfg, quit)
+}
+
+// Monitor runs every group's checks on a loop, in parallel, until quit is
+// closed.
+func Monitor(cfg *Config, quit chan struct{}) {
+	var wg sync.WaitGroup
+
+	for _, group := range cfg.Groups {
+		wg.Add(1)
+
+		go func(group *Group) {
+			defer wg.Done()
+			monitorGroup(group, cfg.Interval, quit)
+		}(group)
+	}
+
+	wg.Wait()
+}
+
+// monitorGroup runs every host's checks in group once immediately, and then
+// again every interval, until quit is closed.
+func monitorGroup(group *Group, interval time.Duration, quit chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	runRound(group)
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			runRound(group)
+		}
+	}
+}
+
+// runRound runs every check for every host in group once, in parallel, and
+// prints a pass/fail line per host.
+func runRound(group *Group) {
+	var wg sync.WaitGroup
+
+	for _, host := range group.Hosts {
+		wg.Add(1)
+
+		go func(host *Host) {
+			defer wg.Done()
+
+			for _, check := range host.Checks {
+				if err := check.Check(); err != nil {
+					fmt.Printf("[%s/%s] FAIL: %v\n", group.Name, host.Name, err)
+					return
+				}
+			}
+
+			fmt.Printf("[%s/%s] OK\n", group.Name, host.Name)
+		}(host)
+	}
+
+	wg.Wait()
+}
@@ -1,72 +1,124 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // ping sends a ping request through the provided connection to ipAddr, and
 // sends the new request to the requests channel. id and seq are used to clear
-// the request if it fails. Inspired by
+// the request if it fails. The connection used is chosen based on whether
+// ipAddr is an ipv4 or ipv6 address. The echo body carries a random token and
+// the send timestamp, so the listener's reply can be verified as belonging to
+// this request instead of an off-path guess, and so RTT can be computed even
+// if sequence numbers wrap. Inspired by
 // https://gist.github.com/lmas/c13d1c9de3b2224f9c26435eb56e6ef3
-func Ping(conn *icmp.PacketConn, ipAddr *net.IPAddr, id int, seq int,
+func Ping(conns *Conns, ipAddr *net.IPAddr, id int, seq int,
 	requests chan Request, errors chan Error) {
+	isV4 := ipAddr.IP.To4() != nil
+
+	conn := conns.v6
+	var icmpType icmp.Type = ipv6.ICMPTypeEchoRequest
+	if isV4 {
+		conn = conns.v4
+		icmpType = ipv4.ICMPTypeEcho
+	}
+
+	if conn == nil {
+		err := fmt.Errorf("no icmp listener available for %s", ipAddr)
+		errors <- Error{id, seq, ipAddr, err, [16]byte{}}
+		return
+	}
+
+	var token [16]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		errors <- Error{id, seq, ipAddr, err, token}
+		return
+	}
+
+	// Data is the token, followed by the big-endian send timestamp in
+	// nanoseconds.
+	data := make([]byte, 24)
+	copy(data[:16], token[:])
+	binary.BigEndian.PutUint64(data[16:], uint64(time.Now().UnixNano()))
+
 	// Create the icmp request.
 	m := icmp.Message{
-		Type: ipv4.ICMPTypeEcho, Code: 0,
+		Type: icmpType, Code: 0,
 		Body: &icmp.Echo{
 			ID:   id,
 			Seq:  seq,
-			Data: []byte(ipAddr.String()),
+			Data: data,
 		},
 	}
 
 	// Marshall it into bytes.
 	b, err := m.Marshal(nil)
 	if err != nil {
-		errors <- Error{id, seq, ipAddr, err}
+		errors <- Error{id, seq, ipAddr, err, token}
 		return
 	}
 
 	// Send it.
-	requests <- Request{id, seq}
+	requests <- Request{id, seq, token}
 	n, err := conn.WriteTo(b, ipAddr)
 	if err != nil {
-		errors <- Error{id, seq, ipAddr, err}
+		errors <- Error{id, seq, ipAddr, err, token}
 		return
 	} else if n != len(b) {
 		err := fmt.Errorf("got %v; want %v", n, len(b))
-		errors <- Error{id, seq, ipAddr, err}
+		errors <- Error{id, seq, ipAddr, err, token}
 		return
 	}
 }
 
-func Pinger(conn *icmp.PacketConn, ipAddrs []*net.IPAddr, requests chan Request,
-	errors chan Error, quit chan struct{}) {
-	i := int64(0)
-	l := int64(len(ipAddrs))
+// Pinger pings every address in ipAddrs on its own schedule, sending a ping
+// every interval. If count is greater than 0, each address stops after count
+// pings have been sent; otherwise it continues until quit is closed. quit
+// must be closed (rather than sent to) to stop every target's goroutine at
+// once.
+func Pinger(conns *Conns, ipAddrs []*net.IPAddr, requests chan Request,
+	errors chan Error, quit chan struct{}, interval time.Duration, count int) {
+	var wg sync.WaitGroup
 
-	// Ping each address in a round-robin fashion.
-	for {
-		id := int(i % l)
-		seq := int(i / l)
+	for id, ipAddr := range ipAddrs {
+		wg.Add(1)
 
-		// Send the ping request in a new goroutine.
-		go Ping(conn, ipAddrs[id], id, seq, requests, errors)
+		go func(id int, ipAddr *net.IPAddr) {
+			defer wg.Done()
 
-		// Sleep for 1 millisecond so that the listener's buffer isn't overloaded.
-		time.Sleep(time.Millisecond)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
 
-		i += 1
+			seq := 0
 
-		select {
-		case <-quit:
-			return
-		default:
-		}
+			// Send the first ping immediately, then one every interval.
+			go Ping(conns, ipAddr, id, seq, requests, errors)
+			seq++
+
+			for {
+				select {
+				case <-quit:
+					return
+				case <-ticker.C:
+					if count > 0 && seq >= count {
+						return
+					}
+
+					go Ping(conns, ipAddr, id, seq, requests, errors)
+					seq++
+				}
+			}
+		}(id, ipAddr)
 	}
+
+	wg.Wait()
 }
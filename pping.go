@@ -1,36 +1,24 @@
 package main
 
 import (
+	"encoding/binary"
 	"encoding/csv"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"os"
-	"os/exec"
 	"runtime"
 	"strconv"
 	"time"
-
-	"golang.org/x/net/icmp"
 )
 
-// printError prints an error message, while preserving the proper output
-// format.
-// ANSI sequences from https://en.wikipedia.org/wiki/ANSI_escape_code#CSI_(Control_Sequence_Introducer)_sequences
-func printError(msg string, ipCount int) {
-	// Move terminal cursor up to overwrite the line for url.
-	fmt.Printf("\r\033[%dA\033[K", ipCount+2)
-
-	// Print the error.
-	fmt.Printf("- %s", msg)
-
-	// Clear the next line.
-	fmt.Printf("\r\033[1B\033[K")
-
-	// Move back down and print the quit message again.
-	fmt.Printf("\r\033[%dB\033[K", ipCount+2)
-	fmt.Printf("\nPress q to quit.")
+// pendingRequest is the bookkeeping kept for a request that hasn't yet been
+// matched to a reply or reaped as lost.
+type pendingRequest struct {
+	id       int
+	seq      int
+	sendTime time.Time
 }
 
 // durToString converts a duration to a string of the specified time unit and
@@ -45,9 +33,26 @@ func main() {
 		"Write the results to output_file if provided, in CSV format")
 	maxProcs := flag.Int("p", 0,
 		"Sets the value of runtime.GOMAXPROCS to max_procs. If max_procs is set to -1, pping will print the default value for runtime.GOMAXPROCS and quit.")
+	useUDP := flag.Bool("u", false,
+		"Use unprivileged udp4/udp6 datagram sockets instead of raw icmp sockets, so pping can run without root or CAP_NET_RAW.")
+	interval := flag.Duration("i", time.Second,
+		"Wait interval between sending each ping to a given target.")
+	pingCount := flag.Int("c", 0,
+		"Stop pinging each target after count pings. If count is 0, pinging continues until interrupted.")
+	timeout := flag.Duration("W", time.Second,
+		"Time to wait for a response before considering a ping lost.")
+	configFile := flag.String("f", "",
+		"Run in monitoring mode, using the host groups and checks defined in alrmrc_file, instead of pinging the urls given as arguments.")
 
 	flag.Parse()
 
+	if *interval <= 0 {
+		log.Fatalf("-i must be greater than 0, got %v.\n", *interval)
+	}
+	if *timeout <= 0 {
+		log.Fatalf("-W must be greater than 0, got %v.\n", *timeout)
+	}
+
 	urls := flag.Args()
 
 	if *maxProcs < 0 {
@@ -59,6 +64,11 @@ func main() {
 		runtime.GOMAXPROCS(*maxProcs)
 	}
 
+	if len(*configFile) > 0 {
+		RunConfigFile(*configFile)
+		return
+	}
+
 	if len(urls) == 0 {
 		log.Fatalln("No urls provided.")
 	}
@@ -85,25 +95,26 @@ func main() {
 	matchedUrls := make([]string, 0, len(urls))
 
 	// Create the channels.
-	connReceiver := make(chan *icmp.PacketConn)
+	connReceiver := make(chan *Conns)
 	requestReceiver := make(chan Request)
 	resultReceiver := make(chan Result)
 	errorReceiver := make(chan Error)
 	quit := make(chan struct{})
 
-	// Start the listener in a goroutine, and store the PacketConn.
-	go Listener(connReceiver, resultReceiver, errorReceiver, quit)
-	conn := <-connReceiver
+	// Start the listener in a goroutine, and store the Conns.
+	go Listener(connReceiver, resultReceiver, errorReceiver, quit, *useUDP)
+	conns := <-connReceiver
 
-	// For each provided url, resolve its ip address and make sure it's pingable.
+	// For each provided url, resolve its ip address (ipv4 or ipv6) and make
+	// sure it's pingable.
 	for _, url := range urls {
-		// Resolve the IPv4 address.
-		ip, err := net.ResolveIPAddr("ip4", url)
+		// Resolve the address, preferring whichever family the resolver returns.
+		ip, err := net.ResolveIPAddr("ip", url)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Could not get IP for %s: %v\n", url, err)
 		} else {
 			// Attempt to ping the resolved ip address.
-			go Ping(conn, ip, 0, 0, requestReceiver, errorReceiver)
+			go Ping(conns, ip, 0, 0, requestReceiver, errorReceiver)
 			<-requestReceiver
 
 			select {
@@ -130,118 +141,126 @@ func main() {
 		}
 	}
 
-	// Create the requests and results with the cap ipCount.
-	requests := make([]map[int]time.Time, ipCount)
-	for i, l := 0, ipCount; i < l; i++ {
-		requests[i] = map[int]time.Time{}
-	}
+	// requests tracks outstanding pings by the random token embedded in their
+	// payload, rather than by (id, seq). This both survives udp4/udp6 sockets
+	// overriding the echo ID, and lets a reply be verified as actually
+	// belonging to one of our requests before it's accepted.
+	requests := make(map[[16]byte]pendingRequest)
 
+	// results accumulates each ping's RTT per target, in the format the CSV
+	// writer expects.
 	results := make([][]string, ipCount)
-	mins := make([]time.Duration, ipCount)
-	maxs := make([]time.Duration, ipCount)
-	tots := make([]time.Duration, ipCount)
-	cnts := make([]int64, ipCount)
+	addrs := make([]string, ipCount)
 	for i, l := 0, ipCount; i < l; i++ {
 		results[i] = []string{matchedUrls[i], ipAddrs[i].String()}
-
-		// min and max duration from time package constants.
-		mins[i] = 1<<63 - 1
-		maxs[i] = -1 << 63
-		tots[i] = 0
-		cnts[i] = 0
+		addrs[i] = ipAddrs[i].String()
 	}
 
-	fmt.Printf("Pinging %d URLs...\n", len(matchedUrls))
+	dashboard, err := NewDashboard(matchedUrls, addrs)
+	if err != nil {
+		log.Fatalf("Unable to start dashboard: %v.\n", err)
+	}
 
 	// Start the looped pinger in a separate routine so that we can handle stuff
 	// in the main routine.
-	go Pinger(conn, ipAddrs, requestReceiver, errorReceiver, quit)
-
-	// Read keyboard input for q.
-	// From https://github.com/pantherman594/tunnel/blob/master/main.go#L165.
-	go func() {
-		// Disable input buffering
-		exec.Command("stty", "-F", "/dev/tty", "cbreak", "min", "1").Run()
-		// Do not display entered characters on the screen
-		exec.Command("stty", "-F", "/dev/tty", "-echo").Run()
-		var b []byte = make([]byte, 1)
-
-		for {
-			os.Stdin.Read(b)
-			if b[0] == 'q' {
-				quit <- struct{}{}
-				quit <- struct{}{}
-				quit <- struct{}{}
-				return
-			}
-		}
-	}()
+	go Pinger(conns, ipAddrs, requestReceiver, errorReceiver, quit, *interval, *pingCount)
+
+	keys := dashboard.Keys()
+
+	// redraw refreshes the dashboard on a fixed cadence, rather than after
+	// every ping, so a burst of replies doesn't flood the terminal.
+	redraw := time.NewTicker(200 * time.Millisecond)
+	defer redraw.Stop()
+
+	// reaper periodically scans for requests that have gone unanswered for
+	// longer than timeout, and counts them as lost.
+	reaper := time.NewTicker(*timeout)
+	defer reaper.Stop()
 
 	startTime := time.Now()
-	fmt.Printf("\nErrors:\n\n")
-	for i := 0; i < ipCount; i++ {
-		fmt.Println()
-	}
-	fmt.Printf("\nPress q to quit.")
 
-	// Listen for requests, results, and errors.
+	// Listen for requests, results, errors, and dashboard events.
 	for {
 		select {
 		case req := <-requestReceiver:
-			requests[req.id][req.seq] = time.Now()
+			requests[req.token] = pendingRequest{req.id, req.seq, time.Now()}
+			dashboard.RecordSent(req.id)
 		case res := <-resultReceiver:
-			if res.id < 0 || res.id >= ipCount {
-				printError(fmt.Sprintf("Received invalid id: %d", res.id), ipCount)
+			if len(res.payload) < 16 {
+				dashboard.LogError("Response received with a truncated payload; unable to verify token.")
 				continue
 			}
 
-			start, ok := requests[res.id][res.seq]
+			var token [16]byte
+			copy(token[:], res.payload[:16])
+
+			pending, ok := requests[token]
 			if !ok {
-				printError(fmt.Sprintf("[%s] Response received without a corresponding request.",
-					matchedUrls[res.id]), ipCount)
+				dashboard.LogError("Response received without a corresponding request (unknown or spoofed token).")
 				continue
 			}
-			delete(requests[res.id], res.seq)
+			delete(requests, token)
 
-			// Calculate the total duration, log it, and store it in results.
-			dur := res.endTime.Sub(start)
-			durMsStr := durToString(dur, time.Millisecond, 4)
-
-			results[res.id] = append(results[res.id], durMsStr)
-
-			if dur < mins[res.id] {
-				mins[res.id] = dur
-			}
-			if dur > maxs[res.id] {
-				maxs[res.id] = dur
+			id := pending.id
+			if id < 0 || id >= ipCount {
+				dashboard.LogError(fmt.Sprintf("Received invalid id: %d", id))
+				continue
 			}
-			tots[res.id] += dur
-			cnts[res.id] += 1
 
-			count := cnts[res.id]
+			// Calculate the total duration, preferring the send timestamp embedded
+			// in the payload; fall back to the locally recorded send time if the
+			// payload was truncated before the timestamp.
+			var dur time.Duration
+			if len(res.payload) >= 24 {
+				sentNanos := int64(binary.BigEndian.Uint64(res.payload[16:24]))
+				dur = res.endTime.Sub(time.Unix(0, sentNanos))
+			} else {
+				dur = res.endTime.Sub(pending.sendTime)
+			}
 
-			// Only update status every 100 pings.
-			if count == 1 || count%100 == 0 {
-				min := durToString(mins[res.id], time.Millisecond, 2)
-				max := durToString(maxs[res.id], time.Millisecond, 2)
-				avg := durToString(tots[res.id]/time.Duration(count), time.Millisecond, 2)
+			results[id] = append(results[id], durToString(dur, time.Millisecond, 4))
+			dashboard.RecordResult(id, dur)
+		case e := <-errorReceiver:
+			delete(requests, e.token)
+			if e.id >= 0 && e.id < ipCount {
+				dashboard.RecordLost(e.id)
+			}
 
-				// Move terminal cursor up to overwrite the line for url.
-				fmt.Printf("\r\033[%dA\033[K", (ipCount-res.id)+1)
-				fmt.Printf("[%d %s] Pinged %s in %sms. %d pings. min/max/avg: %s/%s/%sms",
-					res.id, matchedUrls[res.id], ipAddrs[res.id], durMsStr, count, min,
-					max, avg)
+			dashboard.LogError(fmt.Sprintf("[%d]: %v", e.id, e.err))
+		case <-reaper.C:
+			now := time.Now()
 
-				// Move cursor back down.
-				fmt.Printf("\r\033[%dB", (ipCount-res.id)+1)
+			for token, pending := range requests {
+				if now.Sub(pending.sendTime) >= *timeout {
+					delete(requests, token)
+					dashboard.RecordLost(pending.id)
+				}
 			}
-		case e := <-errorReceiver:
-			if e.id >= 0 && e.id < ipCount {
-				delete(requests[e.id], e.seq)
+		case <-redraw.C:
+			if !dashboard.Paused() {
+				dashboard.Draw()
+			}
+		case key, ok := <-keys:
+			if !ok {
+				close(quit)
+				continue
 			}
 
-			printError(fmt.Sprintf("[%d]: %v", e.id, e.err), ipCount)
+			switch key {
+			case 'q':
+				// Closing quit, rather than sending on it, broadcasts to every
+				// per-target Pinger goroutine and both Listener loops at once.
+				close(quit)
+			case 's':
+				dashboard.CycleSort()
+			case 'p':
+				dashboard.TogglePause()
+			case 'r':
+				dashboard.Reset()
+			}
 		case <-quit:
+			dashboard.Close()
+
 			dur := time.Since(startTime)
 			durSec := float64(dur) / float64(time.Second)
 			totalPings := 0
@@ -255,6 +274,20 @@ func main() {
 			fmt.Printf("Pinged %d times in %0.4f seconds (%0.2f pings/sec).\n",
 				totalPings, durSec, pingsPerSec)
 
+			for _, t := range dashboard.targets {
+				fmt.Printf("\n--- %s ping statistics ---\n", t.url)
+				fmt.Printf("%d packets transmitted, %d received, %0.2f%% packet loss\n",
+					t.sent, t.recv, t.lossPct())
+
+				if t.recv > 0 {
+					fmt.Printf("rtt min/avg/max/stddev = %s/%s/%s/%s ms\n",
+						durToString(t.min, time.Millisecond, 3),
+						durToString(t.avg(), time.Millisecond, 3),
+						durToString(t.max, time.Millisecond, 3),
+						strconv.FormatFloat(t.stddevMs(), 'f', 3, 64))
+				}
+			}
+
 			if writer != nil {
 				for _, v := range results {
 					err := writer.Write(v)
@@ -264,7 +297,6 @@ func main() {
 				}
 			}
 			return
-		default:
 		}
 	}
 }
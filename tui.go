@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// sparklineHistory is the number of past RTTs kept per target for the
+// sparkline column.
+const sparklineHistory = 30
+
+var sparkChars = []rune{'▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+
+// targetStats holds the running statistics and rolling RTT history the
+// dashboard renders for a single target.
+type targetStats struct {
+	url  string
+	addr string
+
+	history []float64 // last sparklineHistory RTTs in ms, oldest first
+	last    time.Duration
+	min     time.Duration
+	max     time.Duration
+	tot     time.Duration
+	sumSqMs float64
+	sent    int64
+	recv    int64
+	lost    int64
+}
+
+func newTargetStats(url, addr string) *targetStats {
+	return &targetStats{
+		url:  url,
+		addr: addr,
+		min:  1<<63 - 1,
+		max:  -1 << 63,
+	}
+}
+
+func (t *targetStats) reset() {
+	*t = *newTargetStats(t.url, t.addr)
+}
+
+// record adds a successfully received ping's RTT to the target's stats.
+func (t *targetStats) record(dur time.Duration) {
+	t.last = dur
+	t.recv++
+	t.tot += dur
+
+	if dur < t.min {
+		t.min = dur
+	}
+	if dur > t.max {
+		t.max = dur
+	}
+
+	ms := float64(dur) / float64(time.Millisecond)
+	t.sumSqMs += ms * ms
+
+	t.history = append(t.history, ms)
+	if len(t.history) > sparklineHistory {
+		t.history = t.history[len(t.history)-sparklineHistory:]
+	}
+}
+
+func (t *targetStats) avg() time.Duration {
+	if t.recv == 0 {
+		return 0
+	}
+	return t.tot / time.Duration(t.recv)
+}
+
+func (t *targetStats) stddevMs() float64 {
+	if t.recv == 0 {
+		return 0
+	}
+
+	meanMs := float64(t.avg()) / float64(time.Millisecond)
+	variance := t.sumSqMs/float64(t.recv) - meanMs*meanMs
+	if variance < 0 {
+		variance = 0
+	}
+	return math.Sqrt(variance)
+}
+
+func (t *targetStats) lossPct() float64 {
+	if t.sent == 0 {
+		return 0
+	}
+	return 100 * float64(t.lost) / float64(t.sent)
+}
+
+// sparkline renders history as a string of unicode block characters scaled
+// between its own min and max.
+func sparkline(history []float64) string {
+	if len(history) == 0 {
+		return ""
+	}
+
+	lo, hi := history[0], history[0]
+	for _, v := range history {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+
+	spark := make([]rune, len(history))
+	for i, v := range history {
+		if hi == lo {
+			spark[i] = sparkChars[0]
+			continue
+		}
+
+		frac := (v - lo) / (hi - lo)
+		idx := int(frac * float64(len(sparkChars)-1))
+		spark[i] = sparkChars[idx]
+	}
+
+	return string(spark)
+}
+
+// sortColumn identifies which column the dashboard's rows are sorted by.
+type sortColumn int
+
+const (
+	sortByHost sortColumn = iota
+	sortByLast
+	sortByAvg
+	sortByLoss
+	numSortColumns
+)
+
+func (s sortColumn) String() string {
+	switch s {
+	case sortByLast:
+		return "last"
+	case sortByAvg:
+		return "avg"
+	case sortByLoss:
+		return "loss"
+	default:
+		return "host"
+	}
+}
+
+// Dashboard is a live tcell table of per-target ping statistics, one row per
+// target, with a rolling RTT sparkline column.
+type Dashboard struct {
+	screen  tcell.Screen
+	targets []*targetStats
+	errors  []string
+	sortBy  sortColumn
+	paused  bool
+}
+
+// NewDashboard opens a tcell screen and prepares one row per target.
+func NewDashboard(matchedUrls []string, addrs []string) (*Dashboard, error) {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return nil, err
+	}
+	if err := screen.Init(); err != nil {
+		return nil, err
+	}
+
+	targets := make([]*targetStats, len(matchedUrls))
+	for i := range matchedUrls {
+		targets[i] = newTargetStats(matchedUrls[i], addrs[i])
+	}
+
+	return &Dashboard{screen: screen, targets: targets}, nil
+}
+
+// Close restores the terminal to its normal mode.
+func (d *Dashboard) Close() {
+	d.screen.Fini()
+}
+
+// RecordSent marks that a ping was sent to target id, whether or not it is
+// ever answered.
+func (d *Dashboard) RecordSent(id int) {
+	d.targets[id].sent++
+}
+
+// RecordResult adds a successfully received ping's RTT to target id's stats.
+func (d *Dashboard) RecordResult(id int, dur time.Duration) {
+	d.targets[id].record(dur)
+}
+
+// RecordLost marks a ping to target id as lost.
+func (d *Dashboard) RecordLost(id int) {
+	d.targets[id].lost++
+}
+
+// LogError appends msg to the dashboard's small scrolling error log.
+func (d *Dashboard) LogError(msg string) {
+	d.errors = append(d.errors, msg)
+	if len(d.errors) > 5 {
+		d.errors = d.errors[len(d.errors)-5:]
+	}
+}
+
+// Paused reports whether the dashboard is currently paused.
+func (d *Dashboard) Paused() bool {
+	return d.paused
+}
+
+// TogglePause pauses or resumes redrawing the dashboard. Statistics keep
+// being recorded while it is paused.
+func (d *Dashboard) TogglePause() {
+	d.paused = !d.paused
+}
+
+// CycleSort advances the dashboard to the next sort column.
+func (d *Dashboard) CycleSort() {
+	d.sortBy = (d.sortBy + 1) % numSortColumns
+}
+
+// Reset clears every target's accumulated statistics.
+func (d *Dashboard) Reset() {
+	for _, t := range d.targets {
+		t.reset()
+	}
+}
+
+// Keys returns a channel of recognized keypresses ('q', 's', 'p', 'r'). It is
+// closed when the underlying screen is finalized.
+func (d *Dashboard) Keys() <-chan rune {
+	keys := make(chan rune)
+
+	go func() {
+		defer close(keys)
+
+		for {
+			ev := d.screen.PollEvent()
+			switch ev := ev.(type) {
+			case *tcell.EventKey:
+				switch ev.Rune() {
+				case 'q', 's', 'p', 'r':
+					keys <- ev.Rune()
+				}
+			case nil:
+				return
+			}
+		}
+	}()
+
+	return keys
+}
+
+// Draw renders the current table of targets to the screen.
+func (d *Dashboard) Draw() {
+	d.screen.Clear()
+
+	rows := make([]*targetStats, len(d.targets))
+	copy(rows, d.targets)
+	sort.SliceStable(rows, func(i, j int) bool {
+		switch d.sortBy {
+		case sortByLast:
+			return rows[i].last > rows[j].last
+		case sortByAvg:
+			return rows[i].avg() > rows[j].avg()
+		case sortByLoss:
+			return rows[i].lossPct() > rows[j].lossPct()
+		default:
+			return rows[i].url < rows[j].url
+		}
+	})
+
+	header := fmt.Sprintf("%-24s %-16s %8s %8s %8s %8s %7s  %s",
+		"HOST", "IP", "LAST", "MIN", "AVG", "MAX", "LOSS%", "HISTORY")
+	drawText(d.screen, 0, 0, tcell.StyleDefault.Bold(true), header)
+
+	for i, t := range rows {
+		row := fmt.Sprintf("%-24s %-16s %8s %8s %8s %8s %6.1f%%  %s",
+			truncate(t.url, 24), truncate(t.addr, 16),
+			durMsStr(t.last), durMsStr(t.min), durMsStr(t.avg()), durMsStr(t.max),
+			t.lossPct(), sparkline(t.history))
+		drawText(d.screen, 0, i+1, tcell.StyleDefault, row)
+	}
+
+	y := len(rows) + 2
+
+	status := fmt.Sprintf("q: quit  s: sort (%s)  p: %s  r: reset",
+		d.sortBy, pauseLabel(d.paused))
+	drawText(d.screen, 0, y, tcell.StyleDefault.Dim(true), status)
+
+	for i, msg := range d.errors {
+		drawText(d.screen, 0, y+2+i, tcell.StyleDefault.Foreground(tcell.ColorRed), msg)
+	}
+
+	d.screen.Show()
+}
+
+func pauseLabel(paused bool) string {
+	if paused {
+		return "resume"
+	}
+	return "pause"
+}
+
+func drawText(screen tcell.Screen, x, y int, style tcell.Style, text string) {
+	col := 0
+	for _, r := range text {
+		screen.SetContent(x+col, y, r, nil, style)
+		col++
+	}
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func durMsStr(dur time.Duration) string {
+	if dur == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(dur)/float64(time.Millisecond), 'f', 1, 64) + "ms"
+}
@@ -6,14 +6,14 @@ import (
 )
 
 type Request struct {
-	id  int
-	seq int
+	id    int
+	seq   int
+	token [16]byte
 }
 
 type Result struct {
-	id      int
-	seq     int
 	endTime time.Time
+	payload []byte
 }
 
 type Error struct {
@@ -21,4 +21,5 @@ type Error struct {
 	seq    int
 	ipAddr *net.IPAddr
 	err    error
+	token  [16]byte
 }